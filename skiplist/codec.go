@@ -0,0 +1,119 @@
+package skiplist
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"unsafe"
+)
+
+//ErrCorrupt is returned by Decode when the stream was not written by Encode
+var ErrCorrupt = errors.New("skiplist: corrupt stream")
+
+//ErrDuplicates is returned by Decode when the stream holds duplicate keys,
+//written by a list created WithDuplicates, and the list was not.
+var ErrDuplicates = errors.New("skiplist: stream has duplicate keys, decode it WithDuplicates")
+
+//Encode writes a Snapshot of the list to w, keys are in ascending order.
+//
+//enc is called on every value to turn it into bytes.
+//
+//The stream of a list created WithDuplicates can only be decoded
+//by a list created WithDuplicates.
+func (h *Header) Encode(w io.Writer, enc func(unsafe.Pointer) ([]byte, error)) error {
+	kvs := h.Snapshot()
+	bw := bufio.NewWriter(w)
+	buf := make([]byte, binary.MaxVarintLen64)
+	if _, err := bw.Write(buf[:binary.PutUvarint(buf, uint64(len(kvs)))]); err != nil {
+		return err
+	}
+	for _, kv := range kvs {
+		b, err := enc(kv.Value)
+		if err != nil {
+			return err
+		}
+		if _, err := bw.Write(buf[:binary.PutVarint(buf, int64(kv.Key))]); err != nil {
+			return err
+		}
+		if _, err := bw.Write(buf[:binary.PutUvarint(buf, uint64(len(b)))]); err != nil {
+			return err
+		}
+		if _, err := bw.Write(b); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+//Decode reads a list written by Encode from r and Sets every entry of it.
+//
+//dec is called on the bytes of every value to turn them back into a value,
+//the bytes are reused after dec returns.
+//Nothing is Set if the stream is truncated (io.ErrUnexpectedEOF), corrupt
+//(ErrCorrupt), holds duplicate keys while h was not created WithDuplicates
+//(ErrDuplicates) or if dec returns an error.
+func (h *Header) Decode(r io.Reader, dec func([]byte) (unsafe.Pointer, error)) error {
+	br := bufio.NewReader(r)
+	count, err := binary.ReadUvarint(br)
+	if err != nil {
+		return unexpectedEOF(err)
+	}
+	var kvs []KV
+	var buf bytes.Buffer
+	for i := uint64(0); i < count; i++ {
+		key, err := binary.ReadVarint(br)
+		if err != nil {
+			return unexpectedEOF(err)
+		}
+		if key <= math.MinInt32 || key >= math.MaxInt32 || (i > 0 && int(key) < kvs[i-1].Key) {
+			return ErrCorrupt
+		}
+		if i > 0 && int(key) == kvs[i-1].Key && !h.dups {
+			return ErrDuplicates
+		}
+		size, err := binary.ReadUvarint(br)
+		if err != nil {
+			return unexpectedEOF(err)
+		}
+		if size > math.MaxInt64 {
+			return ErrCorrupt
+		}
+		buf.Reset()
+		if _, err := io.CopyN(&buf, br, int64(size)); err != nil {
+			return unexpectedEOF(err)
+		}
+		ptr, err := dec(buf.Bytes())
+		if err != nil {
+			return err
+		}
+		kvs = append(kvs, KV{Key: int(key), Value: ptr})
+	}
+	if h.dups {
+		//Set adds a duplicate before the others,
+		//so they have to be replayed in reverse order
+		for i := 0; i < len(kvs); {
+			j := i + 1
+			for j < len(kvs) && kvs[j].Key == kvs[i].Key {
+				j++
+			}
+			for l, r := i, j-1; l < r; l, r = l+1, r-1 {
+				kvs[l], kvs[r] = kvs[r], kvs[l]
+			}
+			i = j
+		}
+	}
+	h.SetBatch(kvs)
+	return nil
+}
+
+//unexpectedEOF turns io.EOF into io.ErrUnexpectedEOF,
+//a stream always ends after its last value.
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
@@ -0,0 +1,143 @@
+package skiplist
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"testing"
+	"unsafe"
+)
+
+func encodeString(ptr unsafe.Pointer) ([]byte, error) {
+	return []byte(*(*string)(ptr)), nil
+}
+
+func decodeString(b []byte) (unsafe.Pointer, error) {
+	s := string(b)
+	return unsafe.Pointer(&s), nil
+}
+
+func TestEncodeDecode(t *testing.T) {
+	sl := New()
+	values := []string{"zero", "", "two", "minus one"}
+	sl.Set(0, unsafe.Pointer(&values[0]))
+	sl.Set(1, unsafe.Pointer(&values[1]))
+	sl.Set(2, unsafe.Pointer(&values[2]))
+	sl.Set(-1, unsafe.Pointer(&values[3]))
+
+	var buf bytes.Buffer
+	if err := sl.Encode(&buf, encodeString); err != nil {
+		t.Fatal(err)
+	}
+	decoded := New()
+	if err := decoded.Decode(bytes.NewReader(buf.Bytes()), decodeString); err != nil {
+		t.Fatal(err)
+	}
+	expected, got := sl.Snapshot(), decoded.Snapshot()
+	if len(expected) != len(got) {
+		t.Fatalf("expected %d items, got %d", len(expected), len(got))
+	}
+	for i := range expected {
+		if expected[i].Key != got[i].Key || *(*string)(expected[i].Value) != *(*string)(got[i].Value) {
+			t.Fatalf("item %d: expected %d:%q, got %d:%q", i,
+				expected[i].Key, *(*string)(expected[i].Value), got[i].Key, *(*string)(got[i].Value))
+		}
+	}
+
+	//every truncation is an error
+	for i := 0; i < buf.Len(); i++ {
+		truncated := New()
+		if err := truncated.Decode(bytes.NewReader(buf.Bytes()[:i]), decodeString); err != io.ErrUnexpectedEOF {
+			t.Fatalf("decoding %d of %d bytes: expected io.ErrUnexpectedEOF, got %v", i, buf.Len(), err)
+		}
+		if truncated.Len() != 0 {
+			t.Fatal("truncated stream was partially decoded")
+		}
+	}
+}
+
+func TestEncodeDecodeEmpty(t *testing.T) {
+	var buf bytes.Buffer
+	if err := New().Encode(&buf, encodeString); err != nil {
+		t.Fatal(err)
+	}
+	decoded := New()
+	if err := decoded.Decode(&buf, decodeString); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Len() != 0 {
+		t.Fatal("expected list to be of length 0, got ", decoded.Len())
+	}
+}
+
+func TestDecodeCorrupt(t *testing.T) {
+	put := func(values ...int64) []byte {
+		var b []byte
+		buf := make([]byte, binary.MaxVarintLen64)
+		b = append(b, buf[:binary.PutUvarint(buf, uint64(len(values)))]...)
+		for _, v := range values {
+			b = append(b, buf[:binary.PutVarint(buf, v)]...)
+			b = append(b, 0) // empty value
+		}
+		return b
+	}
+	for name, stream := range map[string][]byte{
+		"unsorted":     put(2, 1),
+		"left bound":   put(-1 << 31),
+		"right bound":  put(1<<31 - 1),
+		"huge value":   {1, 0, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x01},
+		"huge varint":  {0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x01},
+		"huge length":  {0xff, 0xff, 0xff, 0xff, 0x0f},
+		"empty stream": {},
+	} {
+		sl := New()
+		if err := sl.Decode(bytes.NewReader(stream), decodeString); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+		if sl.Len() != 0 {
+			t.Errorf("%s: corrupt stream was partially decoded", name)
+		}
+	}
+
+	if err := New().Decode(bytes.NewReader(put(1, 1)), decodeString); err != ErrDuplicates {
+		t.Fatalf("expected ErrDuplicates, got %v", err)
+	}
+
+	failing := errors.New("failing")
+	err := New().Decode(bytes.NewReader(put(1)), func([]byte) (unsafe.Pointer, error) { return nil, failing })
+	if err != failing {
+		t.Fatalf("expected the error of dec, got %v", err)
+	}
+}
+
+func TestEncodeDecodeDuplicates(t *testing.T) {
+	sl := New(WithDuplicates())
+	values := []string{"a", "b", "c", "d"}
+	sl.Set(1, unsafe.Pointer(&values[0]))
+	sl.Set(1, unsafe.Pointer(&values[1]))
+	sl.Set(0, unsafe.Pointer(&values[2]))
+	sl.Set(1, unsafe.Pointer(&values[3]))
+
+	var buf bytes.Buffer
+	if err := sl.Encode(&buf, encodeString); err != nil {
+		t.Fatal(err)
+	}
+	if err := New().Decode(bytes.NewReader(buf.Bytes()), decodeString); err != ErrDuplicates {
+		t.Fatalf("expected ErrDuplicates decoding without WithDuplicates, got %v", err)
+	}
+	decoded := New(WithDuplicates())
+	if err := decoded.Decode(&buf, decodeString); err != nil {
+		t.Fatal(err)
+	}
+	expected, got := sl.Snapshot(), decoded.Snapshot()
+	if len(expected) != len(got) {
+		t.Fatalf("expected %d items, got %d", len(expected), len(got))
+	}
+	for i := range expected {
+		if expected[i].Key != got[i].Key || *(*string)(expected[i].Value) != *(*string)(got[i].Value) {
+			t.Fatalf("item %d: expected %d:%q, got %d:%q", i,
+				expected[i].Key, *(*string)(expected[i].Value), got[i].Key, *(*string)(got[i].Value))
+		}
+	}
+}
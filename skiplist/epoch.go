@@ -0,0 +1,158 @@
+package skiplist
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+//epochShards is the number of reader counters per epoch,
+//readers are spread on them by key so that they don't all hit the same one.
+const epochShards = 16
+
+//epochs implements epoch based reclamation, see WithReclamation.
+//
+//Every operation on the list enters the current epoch and exits it
+//when done. A removed node is retired in the current epoch e, and is
+//reclaimed when the epoch goes from e+1 to e+2: at that point nobody
+//who could have seen it before it was unlinked is still running.
+//
+//The epoch goes from e to e+1 when no reader is left in e-1, so only
+//three epochs can have readers at a time. It is moved on, as far as what
+//was retired allows, by every retire and by the last reader to exit an
+//epoch that is not the current one.
+type epochs struct {
+	epoch  uint64
+	active [3][epochShards]counter
+
+	lock    sync.Mutex // protects retired & epoch increments
+	retired [3][]*node
+
+	spread uint32 // round robin on counters for operations without a key
+}
+
+//counter is an atomic counter alone on its cache line
+type counter struct {
+	n int64
+	_ [56]byte
+}
+
+//WithReclamation enables epoch based reclamation: removed nodes are
+//cleared, dropping their value & their links to other nodes, as soon as
+//no operation that could still see them is running.
+//
+//Until then retired nodes are kept alive by the list: what is held is
+//bounded by what gets removed while the longest running operation, like a
+//Snapshot, is in progress, and is released once it exits.
+//This does not save memory over the garbage collector, that already frees
+//removed nodes as soon as nothing points to them: BenchmarkChurnReclamation
+//shows a higher live heap than BenchmarkChurn. Every operation is also
+//counted in & out.
+func WithReclamation() Option {
+	return func(h *Header) {
+		h.epochs = &epochs{}
+	}
+}
+
+//enter must be called before anything reads the nodes of the list,
+//it returns the epoch to pass to exit.
+//
+//v is only used to spread readers on counters, operations without
+//a key get one from spread.
+func (h *Header) enter(v int) (epoch uint64) {
+	if h.epochs == nil {
+		return 0
+	}
+	shard := uint(v) % epochShards
+	for {
+		epoch = atomic.LoadUint64(&h.epochs.epoch)
+		c := &h.epochs.active[epoch%3][shard]
+		atomic.AddInt64(&c.n, 1)
+		if atomic.LoadUint64(&h.epochs.epoch) == epoch {
+			return epoch
+		}
+		//the epoch moved on before we were counted in, try again
+		atomic.AddInt64(&c.n, -1)
+	}
+}
+
+//exit must be called once done with the nodes of the list,
+//with the v given to enter.
+func (h *Header) exit(v int, epoch uint64) {
+	ep := h.epochs
+	if ep == nil {
+		return
+	}
+	left := atomic.AddInt64(&ep.active[epoch%3][uint(v)%epochShards].n, -1)
+	if left == 0 && epoch != atomic.LoadUint64(&ep.epoch) {
+		//we could have been the last one holding the epoch back
+		ep.advance()
+	}
+}
+
+//spread returns a v for enter & exit to operations without a key.
+func (h *Header) spread() int {
+	if h.epochs == nil {
+		return 0
+	}
+	return int(atomic.AddUint32(&h.epochs.spread, 1))
+}
+
+//retire parks n, that was just unlinked, until it can be reclaimed.
+func (h *Header) retire(n *node) {
+	ep := h.epochs
+	if ep == nil {
+		return
+	}
+	ep.lock.Lock()
+	epoch := ep.epoch
+	ep.retired[epoch%3] = append(ep.retired[epoch%3], n)
+	ep.lock.Unlock()
+	ep.advance()
+}
+
+//advance moves the epoch on for as long as something retired is left
+//and readers allow it.
+func (ep *epochs) advance() {
+	ep.lock.Lock()
+	for ep.hasRetired() && ep.tryAdvance() {
+	}
+	ep.lock.Unlock()
+}
+
+//hasRetired returns true if some node waits to be reclaimed.
+//
+//ep.lock must be held
+func (ep *epochs) hasRetired() bool {
+	return len(ep.retired[0]) != 0 || len(ep.retired[1]) != 0 || len(ep.retired[2]) != 0
+}
+
+//tryAdvance moves to the next epoch if no reader is left in the previous one,
+//reclaiming what was retired in it.
+//
+//returns false if a reader is still in the previous epoch.
+//
+//ep.lock must be held
+func (ep *epochs) tryAdvance() bool {
+	epoch := ep.epoch
+	prev := (epoch + 2) % 3
+	for shard := range ep.active[prev] {
+		if atomic.LoadInt64(&ep.active[prev][shard].n) != 0 {
+			return false
+		}
+	}
+	for i, n := range ep.retired[prev] {
+		n.reclaim()
+		ep.retired[prev][i] = nil
+	}
+	ep.retired[prev] = ep.retired[prev][:0]
+	atomic.StoreUint64(&ep.epoch, epoch+1)
+	return true
+}
+
+//reclaim drops everything n points to
+func (n *node) reclaim() {
+	atomic.StorePointer(&n.value, nil)
+	for layer := range n.nexts {
+		n.nexts.set(layer, nil)
+	}
+}
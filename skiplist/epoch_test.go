@@ -0,0 +1,212 @@
+package skiplist
+
+import (
+	"math/rand"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+	"unsafe"
+)
+
+func TestReclamation(t *testing.T) {
+	sl := New(WithReclamation())
+	values := make([]int, 100)
+	var removed []*node
+	for round := 0; round < 10; round++ {
+		for i := range values {
+			sl.Set(i, unsafe.Pointer(&values[i]))
+		}
+		for i := range values {
+			n := sl.remove(i)
+			if n == nil {
+				t.Fatalf("failed to remove %d", i)
+			}
+			removed = append(removed, n)
+		}
+	}
+	if sl.Len() != 0 {
+		t.Fatal("expected list to be of length 0, got ", sl.Len())
+	}
+	//nobody is reading, every epoch moves on and only
+	//what was retired in the last two is left
+	left := len(sl.epochs.retired[0]) + len(sl.epochs.retired[1]) + len(sl.epochs.retired[2])
+	if left > 2 {
+		t.Fatalf("%d nodes are still retired", left)
+	}
+	for _, n := range removed[:len(removed)-left] {
+		if n.value != nil {
+			t.Fatalf("node %d was not reclaimed", n.key)
+		}
+		for layer := range n.nexts {
+			if n.nexts.get(layer) != nil {
+				t.Fatalf("node %d still points to other nodes", n.key)
+			}
+		}
+	}
+}
+
+func TestReclamationReader(t *testing.T) {
+	sl := New(WithReclamation())
+	sl.Set(1, unsafe.Pointer(nil))
+	//a reader that started before the removal
+	epoch := sl.enter(1)
+	n := sl.remove(1)
+	for i := 0; i < 100; i++ {
+		sl.Set(2, unsafe.Pointer(nil))
+		sl.Remove(2)
+	}
+	if n.nexts.get(0) == nil {
+		t.Fatal("node was reclaimed while someone could still read it")
+	}
+	sl.exit(1, epoch)
+	for i := 0; i < 2; i++ {
+		sl.Set(2, unsafe.Pointer(nil))
+		sl.Remove(2)
+	}
+	if n.nexts.get(0) != nil {
+		t.Fatal("node was not reclaimed once nobody could read it")
+	}
+}
+
+func TestReclamationAfterReader(t *testing.T) {
+	sl := New(WithReclamation())
+	in := 1000
+	for i := 0; i < in; i++ {
+		sl.Set(i, unsafe.Pointer(new([1024]byte)))
+	}
+	epoch := sl.enter(0)
+	removed := make([]*node, 0, in)
+	for i := 0; i < in; i++ {
+		removed = append(removed, sl.remove(i))
+	}
+	if len(sl.epochs.retired[0])+len(sl.epochs.retired[1])+len(sl.epochs.retired[2]) != in {
+		t.Fatal("nodes were reclaimed while someone could still read them")
+	}
+	//no more Remove, exiting is enough
+	sl.exit(0, epoch)
+	if left := len(sl.epochs.retired[0]) + len(sl.epochs.retired[1]) + len(sl.epochs.retired[2]); left != 0 {
+		t.Fatalf("%d nodes are still retired after the last reader exited", left)
+	}
+	for _, n := range removed {
+		if n.value != nil {
+			t.Fatalf("node %d still holds its value", n.key)
+		}
+	}
+}
+
+func TestParallelReclamation(t *testing.T) {
+	for _, sl := range []*Header{New(WithReclamation()), New(WithReclamation(), WithIndex(), WithDuplicates())} {
+		workers := 8
+		wg := sync.WaitGroup{}
+		wg.Add(workers)
+		for w := 0; w < workers; w++ {
+			go func(w int) {
+				defer wg.Done()
+				rnd := rand.New(rand.NewSource(int64(w)))
+				for i := 0; i < 2000; i++ {
+					v := rnd.Intn(64)
+					switch rnd.Intn(6) {
+					case 0:
+						sl.Set(v, unsafe.Pointer(&v))
+					case 1:
+						sl.Remove(v)
+					case 2:
+						sl.Get(v)
+						sl.Contains(v)
+					case 3:
+						sl.PopMin()
+					case 4:
+						sl.Rank(v)
+						sl.Select(v)
+					case 5:
+						sl.SetBatch([]KV{{Key: v}, {Key: v + 1}, {Key: v + 2}})
+					}
+				}
+			}(w)
+		}
+		wg.Wait()
+		checkList(t, sl)
+		if sl.Stats().Len != sl.Len() {
+			t.Fatalf("list has %d nodes, but length is %d", sl.Stats().Len, sl.Len())
+		}
+	}
+}
+
+func BenchmarkChurn(b *testing.B) {
+	benchmarkChurn(b)
+}
+
+func BenchmarkChurnReclamation(b *testing.B) {
+	benchmarkChurn(b, WithReclamation())
+}
+
+//benchmarkChurn sets & removes random keys with a value of 1KiB while
+//readers keep running Get & Snapshot, and reports the live heap sampled
+//during the churn: after a GC, while readers still hold nodes.
+func benchmarkChurn(b *testing.B, opts ...Option) {
+	sl := New(opts...)
+	keys := 1 << 12
+	for i := 0; i < keys; i += 2 {
+		sl.Set(i, unsafe.Pointer(new([1024]byte)))
+	}
+
+	done := make(chan struct{})
+	wg := sync.WaitGroup{}
+	readers := 4
+	wg.Add(readers + 1)
+	for r := 0; r < readers; r++ {
+		go func(r int) {
+			defer wg.Done()
+			rnd := rand.New(rand.NewSource(int64(r)))
+			for i := 0; ; i++ {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				if i%64 == 0 {
+					sl.Snapshot()
+				} else {
+					sl.Get(rnd.Intn(keys))
+				}
+			}
+		}(r)
+	}
+	var samples, total, max uint64
+	go func() {
+		defer wg.Done()
+		var stats runtime.MemStats
+		for {
+			select {
+			case <-done:
+				return
+			case <-time.After(10 * time.Millisecond):
+			}
+			runtime.GC()
+			runtime.ReadMemStats(&stats)
+			samples++
+			total += stats.HeapAlloc
+			if stats.HeapAlloc > max {
+				max = stats.HeapAlloc
+			}
+		}
+	}()
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		rnd := rand.New(rand.NewSource(rand.Int63()))
+		for pb.Next() {
+			sl.Set(rnd.Intn(keys), unsafe.Pointer(new([1024]byte)))
+			sl.Remove(rnd.Intn(keys))
+		}
+	})
+	b.StopTimer()
+	close(done)
+	wg.Wait()
+	if samples > 0 {
+		b.ReportMetric(float64(total/samples), "avg-live-bytes")
+		b.ReportMetric(float64(max), "max-live-bytes")
+	}
+	runtime.KeepAlive(sl)
+}
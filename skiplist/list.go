@@ -12,6 +12,7 @@ package skiplist
 
 import (
 	"math"
+	"math/rand"
 	"sync"
 	"sync/atomic"
 	"unsafe"
@@ -21,6 +22,26 @@ import (
 type Header struct {
 	length                      uint32
 	leftSentinel, rightSentinel *node
+
+	maxLevel int        // number of layers of the sentinels
+	p        float64    // probability for a node to go up one more layer
+	gen      *generator // source of levels of new nodes
+	indexed  bool       // maintain widths, see WithIndex
+	dups     bool       // allow duplicate keys, see WithDuplicates
+
+	publish sync.RWMutex // read locked by Set/Remove while they publish, see Snapshot
+
+	waitLock sync.Mutex
+	waiters  map[int]*waiters // keys someone is waiting for, see WaitFor
+	waiting  int32            // len(waiters)
+
+	epochs *epochs // nil unless WithReclamation
+}
+
+//KV is a key & its value
+type KV struct {
+	Key   int
+	Value unsafe.Pointer
 }
 
 //node of a skip list
@@ -28,6 +49,7 @@ type node struct {
 	key         int
 	value       unsafe.Pointer //user stuff
 	nexts       nodeSlice      // slice of *node
+	widths      []int32        // distance to nexts at layer 0, only if indexed
 	marked      bool
 	fullyLinked bool
 	lock        sync.Mutex
@@ -36,10 +58,8 @@ type node struct {
 type nodeSlice []unsafe.Pointer // atomic slice of *node
 // type nodeSlice []*node
 
-func newFullNodeSlice() nodeSlice {
-	var slice [maxlevel]unsafe.Pointer
-	// var slice [maxlevel]*node
-	return slice[:]
+func (h *Header) newFullNodeSlice() nodeSlice {
+	return make(nodeSlice, h.maxLevel)
 }
 func (ns nodeSlice) get(layer int) *node {
 	return (*node)(atomic.LoadPointer(&ns[layer]))
@@ -49,6 +69,12 @@ func (ns nodeSlice) set(layer int, n *node) {
 	atomic.StorePointer(&ns[layer], unsafe.Pointer(n))
 	// ns[layer] = n
 }
+func (n *node) width(layer int) int {
+	return int(atomic.LoadInt32(&n.widths[layer]))
+}
+func (n *node) setWidth(layer, w int) {
+	atomic.StoreInt32(&n.widths[layer], int32(w))
+}
 func (ns nodeSlice) unlock(highest int) {
 	var prev *node
 	for i := highest; i >= 0; i-- {
@@ -60,18 +86,99 @@ func (ns nodeSlice) unlock(highest int) {
 	}
 }
 
+//Option configures a list at creation time, see New.
+type Option func(*Header)
+
+//WithMaxLevel sets the number of layers of the list, n must be in 1..64.
+//
+//Small lists can save memory with a lower value, defaults to 32.
+func WithMaxLevel(n int) Option {
+	if n < 1 || n > 64 {
+		panic("skiplist: max level must be in 1..64")
+	}
+	return func(h *Header) {
+		h.maxLevel = n
+	}
+}
+
+//WithProbability sets the probability for a node present at
+//a layer to also be present at the next one, p must be in (0, 1).
+//
+//Defaults to .5
+func WithProbability(p float64) Option {
+	if !(p > 0 && p < 1) {
+		panic("skiplist: probability must be in (0, 1)")
+	}
+	return func(h *Header) {
+		h.p = p
+	}
+}
+
+//WithRandSource sets the random source used to pick the level of new nodes.
+//
+//It is wrapped to be safe for concurrent use.
+func WithRandSource(src rand.Source) Option {
+	return func(h *Header) {
+		h.gen = newGenerator(src)
+	}
+}
+
+//WithSeed seeds the random source of the list,
+//lists with the same seed & inserts will have the same levels.
+func WithSeed(seed int64) Option {
+	return WithRandSource(rand.NewSource(seed))
+}
+
+//WithIndex makes the list maintain, for every node & layer, the number
+//of nodes skipped by its next pointer, so that Rank & Select are O(log n)
+//instead of O(n).
+//
+//The price is that Set & Remove lock the predecessors of a node on every
+//layer instead of only up to the level of the node.
+func WithIndex() Option {
+	return func(h *Header) {
+		h.indexed = true
+	}
+}
+
+//WithDuplicates makes the list a multimap: Set always adds a new node,
+//even if the key is already in the list.
+//
+//A new node is added before the ones with the same key, and Remove removes
+//the first one, so duplicates are removed last in first out.
+//Get returns the value of the last Set, GetAll returns all of them.
+func WithDuplicates() Option {
+	return func(h *Header) {
+		h.dups = true
+	}
+}
+
 //New valid skiplist !
-func New() *Header {
+func New(opts ...Option) *Header {
 	h := &Header{}
+	for _, opt := range opts {
+		opt(h)
+	}
 	h.Initialize()
 	return h
 }
 
 // Initialize resets the list to a default empty state,
 // not thread safely.
+//
+// Options set by New are kept.
 func (h *Header) Initialize() {
-	left := newFullNodeSlice()
-	right := newFullNodeSlice()
+	if h.maxLevel == 0 {
+		h.maxLevel = maxlevel
+	}
+	if h.p == 0 {
+		h.p = p
+	}
+	if h.gen == nil {
+		h.gen = newGenerator(nil)
+	}
+	left := h.newFullNodeSlice()
+	right := h.newFullNodeSlice()
 	rightMost := &node{
 		key:         int(math.MaxInt32),
 		nexts:       right[:],
@@ -85,6 +192,12 @@ func (h *Header) Initialize() {
 		nexts:       left[:],
 		fullyLinked: true,
 	}
+	if h.indexed {
+		leftMost.widths = make([]int32, h.maxLevel)
+		for i := range leftMost.widths {
+			leftMost.setWidth(i, 1)
+		}
+	}
 
 	h.leftSentinel, h.rightSentinel = leftMost, rightMost
 }
@@ -116,9 +229,21 @@ func (n *node) lowerThan(v int) bool {
 //  -∞ -> -3 -> -2 -> [-1] -> (3) -> 6 -> 9 -> +∞ | maxlevel - 4
 //  -∞ -> -3 -> -2 -> [-1] -> (3) -> 6 -> 9 -> +∞ | 0
 func (h *Header) findNode(v int, preds, succs nodeSlice) (lFound int) {
+	return h.search(v, preds, succs, false)
+}
+
+//search is findNode, if fromPreds every layer starts from preds
+//when they can be used: preds must then be the result of a previous search
+//for a key lower than v.
+func (h *Header) search(v int, preds, succs nodeSlice, fromPreds bool) (lFound int) {
 	lFound = -1
 	left := h.leftSentinel
-	for layer := maxlevel - 1; layer >= 0; layer-- {
+	for layer := h.maxLevel - 1; layer >= 0; layer-- {
+		if fromPreds {
+			if hint := preds.get(layer); hint.key > left.key && hint.lowerThan(v) && !hint.marked {
+				left = hint
+			}
+		}
 		right := left.nexts.get(layer)
 		for right.lowerThan(v) {
 			left = right
@@ -138,31 +263,68 @@ func (h *Header) findNode(v int, preds, succs nodeSlice) (lFound int) {
 //
 //returns false if it was just an edit
 //
-//returns true if it was added
+//returns true if it was added, always the case WithDuplicates
 func (h *Header) Set(v int, ptr unsafe.Pointer) bool {
-	topLayer := generateLevel(maxlevel)
-	preds, succs := newFullNodeSlice(), newFullNodeSlice()
+	epoch := h.enter(v)
+	defer h.exit(v, epoch)
+	preds, succs := h.newFullNodeSlice(), h.newFullNodeSlice()
+	return h.set(v, ptr, preds, succs, false)
+}
+
+//SetBatch sets every key & value of kvs, in order,
+//and returns the number of keys that were added.
+//
+//When kvs is sorted in ascending order every search starts
+//where the previous one ended instead of from the top of the list.
+func (h *Header) SetBatch(kvs []KV) (added int) {
+	preds, succs := h.newFullNodeSlice(), h.newFullNodeSlice()
+	for i, kv := range kvs {
+		//preds could be reclaimed after exit, but they
+		//are marked before, so search won't use them.
+		fromPreds := i > 0 && kvs[i-1].Key < kv.Key
+		epoch := h.enter(kv.Key)
+		if h.set(kv.Key, kv.Value, preds, succs, fromPreds) {
+			added++
+		}
+		h.exit(kv.Key, epoch)
+	}
+	return added
+}
+
+//set is Set, leaving preds pointing right before or at v.
+//
+//if fromPreds the first search starts from preds, see search.
+func (h *Header) set(v int, ptr unsafe.Pointer, preds, succs nodeSlice, fromPreds bool) bool {
+	topLayer := h.gen.generateLevel(h.maxLevel, h.p)
 	for {
-		lFound := h.findNode(v, preds, succs)
-		if lFound != -1 { // node was found
+		lFound := h.search(v, preds, succs, fromPreds)
+		fromPreds = false
+		if lFound != -1 && !h.dups { // node was found
 			nodeFound := succs.get(lFound)
+			h.publish.RLock()
 			if !nodeFound.marked {
 				for !nodeFound.fullyLinked {
 					//make sure everything is valid
 				}
 				//node already in there
 				atomic.StorePointer(&nodeFound.value, ptr)
+				h.publish.RUnlock()
+				for layer := 0; layer <= lFound; layer++ {
+					preds.set(layer, nodeFound)
+				}
 				return false
 			}
+			h.publish.RUnlock()
 			//something is deleting that node
 			//let's try again
 			continue
 		}
 		highestLocked := -1
+		h.publish.RLock()
 
 		var prevPred, pred, succ *node
 		valid := true
-		for layer := 0; valid && layer <= topLayer; layer++ {
+		for layer := 0; valid && layer <= h.lockTop(topLayer); layer++ {
 			pred = preds.get(layer)
 			succ = succs.get(layer)
 			if pred != prevPred {
@@ -174,16 +336,25 @@ func (h *Header) Set(v int, ptr unsafe.Pointer) bool {
 		}
 		if !valid {
 			preds.unlock(highestLocked)
+			h.publish.RUnlock()
 			continue
 		}
 		newNode := newNode(ptr, v, topLayer)
+		if h.indexed {
+			h.insertWidths(newNode, preds)
+		}
 		for layer := 0; layer <= topLayer; layer++ {
 			newNode.nexts.set(layer, succs.get(layer))
 			preds.get(layer).nexts.set(layer, newNode)
 		}
 		newNode.fullyLinked = true
 		preds.unlock(highestLocked)
+		h.publish.RUnlock()
 		atomic.AddUint32(&h.length, 1)
+		h.wake(v)
+		for layer := 0; layer <= topLayer; layer++ {
+			preds.set(layer, newNode)
+		}
 		return true
 	}
 }
@@ -191,53 +362,283 @@ func (h *Header) Set(v int, ptr unsafe.Pointer) bool {
 //Remove node containing v if any
 //
 //return false if a Remove is already in progress for that node
+//
+//WithDuplicates, Remove removes the first node of v that is not being removed,
+//which is the last one that was Set.
 func (h *Header) Remove(v int) bool {
+	epoch := h.enter(v)
+	defer h.exit(v, epoch)
+	return h.remove(v) != nil
+}
+
+//remove unlinks the node containing v and returns it,
+//returns nil if there is no such node or a Remove is already in progress for it
+func (h *Header) remove(v int) *node {
 	var nodeToDelete *node
 	isMarked := false
-	topLayer := -1
-	preds, succs := newFullNodeSlice(), newFullNodeSlice()
+	preds, succs := h.newFullNodeSlice(), h.newFullNodeSlice()
 	for {
 		lFound := h.findNode(v, preds, succs)
-		if !(isMarked || (lFound != -1 && succs.get(lFound).okToDelete(lFound))) {
-			return false
+		if !h.dups && !(isMarked || (lFound != -1 && succs.get(lFound).okToDelete(lFound))) {
+			return nil
 		}
 		if !isMarked {
-			nodeToDelete = succs.get(lFound)
-			topLayer = len(nodeToDelete.nexts) - 1
+			if h.dups {
+				nodeToDelete = firstLive(v, succs)
+				if nodeToDelete == nil {
+					return nil
+				}
+			} else {
+				nodeToDelete = succs.get(lFound)
+			}
+			h.publish.RLock() // held until nodeToDelete is unlinked
 			nodeToDelete.lock.Lock()
 			if nodeToDelete.marked {
 				nodeToDelete.lock.Unlock()
-				return false
+				h.publish.RUnlock()
+				if h.dups {
+					//someone else got it, look for another duplicate
+					continue
+				}
+				return nil
 			}
 			nodeToDelete.marked = true
 			isMarked = true
 		}
-		highestLocked := -1
+		if h.dups && !h.predsOf(nodeToDelete, preds, succs) {
+			continue
+		}
+		if !h.unlink(nodeToDelete, preds, succs) {
+			continue
+		}
+		nodeToDelete.lock.Unlock()
+		h.publish.RUnlock()
+		atomic.AddUint32(&h.length, ^uint32(0))
+		h.retire(nodeToDelete)
+		return nodeToDelete
+	}
+}
 
-		var prevPred, pred, succ *node
-		valid := true
-		for layer := 0; valid && (layer <= topLayer); layer++ {
-			pred = preds.get(layer)
-			succ = succs.get(layer)
-			if pred != prevPred {
-				pred.lock.Lock()
-				highestLocked = layer
-				prevPred = pred
+//lockTop returns the highest layer Set/Remove have to lock
+//for a node going up to topLayer.
+//
+//An indexed list locks every layer, so that nobody else can
+//change the widths under a locked predecessor.
+func (h *Header) lockTop(topLayer int) int {
+	if h.indexed {
+		return h.maxLevel - 1
+	}
+	return topLayer
+}
+
+//insertWidths splits the widths of preds around n, that will be linked right after preds[0].
+//
+//preds are locked on every layer.
+func (h *Header) insertWidths(n *node, preds nodeSlice) {
+	topLayer := len(n.nexts) - 1
+	n.widths = make([]int32, topLayer+1)
+	dist := 1 // distance from preds[layer] to n
+	for layer := 0; layer < h.maxLevel; layer++ {
+		pred := preds.get(layer)
+		if layer > topLayer {
+			pred.setWidth(layer, pred.width(layer)+1)
+			continue
+		}
+		if layer > 0 {
+			//walk from pred to preds[layer-1] one layer down
+			below := preds.get(layer - 1)
+			for curr := pred; curr != below; curr = curr.nexts.get(layer - 1) {
+				dist += curr.width(layer - 1)
 			}
-			valid = !pred.marked && pred.nexts.get(layer) == succ
 		}
-		if !valid {
-			preds.unlock(highestLocked)
+		n.setWidth(layer, pred.width(layer)-dist+1)
+		pred.setWidth(layer, dist)
+	}
+}
+
+//removeWidths merges the widths of n into preds, before n is unlinked.
+//
+//preds are locked on every layer.
+func (h *Header) removeWidths(n *node, preds nodeSlice) {
+	topLayer := len(n.nexts) - 1
+	for layer := 0; layer < h.maxLevel; layer++ {
+		pred := preds.get(layer)
+		if layer > topLayer {
+			pred.setWidth(layer, pred.width(layer)-1)
 			continue
 		}
-		for layer := topLayer; layer >= 0; layer-- {
-			preds.get(layer).nexts.set(layer, nodeToDelete.nexts.get(layer))
+		pred.setWidth(layer, pred.width(layer)+n.width(layer)-1)
+	}
+}
+
+//Rank returns the number of keys strictly lower than v.
+//
+//O(log n) if the list was created WithIndex, O(n) otherwise.
+//The answer is only exact when no Set or Remove is in progress.
+func (h *Header) Rank(v int) (rank int) {
+	epoch := h.enter(v)
+	defer h.exit(v, epoch)
+	if !h.indexed {
+		for curr := h.leftSentinel.nexts.get(0); curr != h.rightSentinel && curr.lowerThan(v); curr = curr.nexts.get(0) {
+			if curr.fullyLinked && !curr.marked {
+				rank++
+			}
 		}
-		nodeToDelete.lock.Unlock()
+		return rank
+	}
+	left := h.leftSentinel
+	for layer := h.maxLevel - 1; layer >= 0; layer-- {
+		for right := left.nexts.get(layer); right != h.rightSentinel && right.lowerThan(v); right = left.nexts.get(layer) {
+			rank += left.width(layer)
+			left = right
+		}
+	}
+	return rank
+}
+
+//Select returns the k-th smallest key of the list & its value,
+//k starts at 0.
+//
+//ok is false if k is out of bounds.
+//
+//O(log n) if the list was created WithIndex, O(n) otherwise.
+//The answer is only exact when no Set or Remove is in progress.
+func (h *Header) Select(k int) (key int, ptr unsafe.Pointer, ok bool) {
+	if k < 0 {
+		return 0, nil, false
+	}
+	spread := h.spread()
+	epoch := h.enter(spread)
+	defer h.exit(spread, epoch)
+	if !h.indexed {
+		for curr := h.leftSentinel.nexts.get(0); curr != h.rightSentinel; curr = curr.nexts.get(0) {
+			if !curr.fullyLinked || curr.marked {
+				continue
+			}
+			if k == 0 {
+				return curr.key, atomic.LoadPointer(&curr.value), true
+			}
+			k--
+		}
+		return 0, nil, false
+	}
+	target := k + 1 // position of the node, leftSentinel is at 0
+	pos := 0
+	left := h.leftSentinel
+	for layer := h.maxLevel - 1; layer >= 0; layer-- {
+		for right := left.nexts.get(layer); right != h.rightSentinel && pos+left.width(layer) <= target; right = left.nexts.get(layer) {
+			pos += left.width(layer)
+			left = right
+		}
+	}
+	if pos != target {
+		return 0, nil, false
+	}
+	return left.key, atomic.LoadPointer(&left.value), true
+}
+
+//unlink locks preds and, if they are still right before n, unlinks the
+//marked node n from every layer.
+//
+//returns false if preds or succs are not valid anymore, search again.
+func (h *Header) unlink(n *node, preds, succs nodeSlice) bool {
+	topLayer := len(n.nexts) - 1
+	highestLocked := -1
+
+	var prevPred, pred, succ *node
+	valid := true
+	for layer := 0; valid && (layer <= h.lockTop(topLayer)); layer++ {
+		pred = preds.get(layer)
+		succ = succs.get(layer)
+		if pred != prevPred {
+			pred.lock.Lock()
+			highestLocked = layer
+			prevPred = pred
+		}
+		//WithDuplicates, succs could be another node of the same key
+		valid = !pred.marked && pred.nexts.get(layer) == succ && (layer > topLayer || succ == n)
+	}
+	if !valid {
 		preds.unlock(highestLocked)
-		atomic.AddUint32(&h.length, ^uint32(0))
-		return true
+		return false
+	}
+	if h.indexed {
+		h.removeWidths(n, preds)
+	}
+	for layer := topLayer; layer >= 0; layer-- {
+		preds.get(layer).nexts.set(layer, n.nexts.get(layer))
 	}
+	preds.unlock(highestLocked)
+	return true
+}
+
+//PopMin removes the smallest key of the list and returns it with its value.
+//
+//ok is false if the list is empty.
+func (h *Header) PopMin() (key int, ptr unsafe.Pointer, ok bool) {
+	spread := h.spread()
+	epoch := h.enter(spread)
+	defer h.exit(spread, epoch)
+	for {
+		n := h.leftSentinel.nexts.get(0)
+		for n != h.rightSentinel && (n.marked || !n.fullyLinked) {
+			n = n.nexts.get(0)
+		}
+		if n == h.rightSentinel {
+			return 0, nil, false
+		}
+		if removed := h.remove(n.key); removed != nil {
+			return removed.key, atomic.LoadPointer(&removed.value), true
+		}
+		//someone else got it, try next one
+	}
+}
+
+//firstLive returns the first node containing v from succs
+//that is fully linked & not being removed, nil if there is none.
+func firstLive(v int, succs nodeSlice) *node {
+	for curr := succs.get(0); curr.contains(v); curr = curr.nexts.get(0) {
+		if curr.fullyLinked && !curr.marked {
+			return curr
+		}
+	}
+	return nil
+}
+
+//predsOf moves preds & succs found for the key of n past the duplicates
+//that are before n, so that every layer of preds is right before n or its
+//successor.
+//
+//returns false if n can't be reached from preds anymore, search again.
+//
+//Nothing is locked, unlink validates the result.
+func (h *Header) predsOf(n *node, preds, succs nodeSlice) bool {
+	first := preds.get(0).nexts.get(0)
+	for curr := first; curr != n; curr = curr.nexts.get(0) {
+		if !curr.contains(n.key) {
+			return false
+		}
+	}
+	for layer := 0; layer < h.maxLevel; layer++ {
+		pred := preds.get(layer)
+		for next := pred.nexts.get(layer); precedes(first, next, n); next = pred.nexts.get(layer) {
+			pred = next
+		}
+		preds.set(layer, pred)
+		succs.set(layer, pred.nexts.get(layer))
+	}
+	return true
+}
+
+//precedes returns true if curr is in the duplicates
+//that go from first up to n, n excluded, on layer 0.
+func precedes(first, curr, n *node) bool {
+	for x := first; x != n && x.contains(n.key); x = x.nexts.get(0) {
+		if x == curr {
+			return true
+		}
+	}
+	return false
 }
 
 func (n *node) okToDelete(lFound int) bool {
@@ -246,16 +647,29 @@ func (n *node) okToDelete(lFound int) bool {
 
 //Contains returns true if v can be found in list
 func (h *Header) Contains(v int) bool {
-	preds, succs := newFullNodeSlice(), newFullNodeSlice()
+	epoch := h.enter(v)
+	defer h.exit(v, epoch)
+	preds, succs := h.newFullNodeSlice(), h.newFullNodeSlice()
 	lFound := h.findNode(v, preds, succs)
+	if h.dups {
+		return firstLive(v, succs) != nil
+	}
 	return lFound != -1 && succs.get(lFound).fullyLinked && !succs.get(lFound).marked
 }
 
 //Get returns (ptr, true) if something was found, (nil, false) otherwise
 func (h *Header) Get(v int) (ptr unsafe.Pointer, found bool) {
-	preds, succs := newFullNodeSlice(), newFullNodeSlice()
+	epoch := h.enter(v)
+	defer h.exit(v, epoch)
+	preds, succs := h.newFullNodeSlice(), h.newFullNodeSlice()
 	lFound := h.findNode(v, preds, succs)
 
+	if h.dups {
+		if n := firstLive(v, succs); n != nil {
+			return atomic.LoadPointer(&n.value), true
+		}
+		return nil, false
+	}
 	if lFound == -1 {
 		return nil, false
 	}
@@ -266,6 +680,79 @@ func (h *Header) Get(v int) (ptr unsafe.Pointer, found bool) {
 	return atomic.LoadPointer(&n.value), true
 }
 
+//GetAll returns every value set at v, the last one Set first.
+//
+//Without WithDuplicates it returns at most one value.
+func (h *Header) GetAll(v int) (ptrs []unsafe.Pointer) {
+	epoch := h.enter(v)
+	defer h.exit(v, epoch)
+	preds, succs := h.newFullNodeSlice(), h.newFullNodeSlice()
+	h.findNode(v, preds, succs)
+	for curr := succs.get(0); curr.contains(v); curr = curr.nexts.get(0) {
+		if curr.fullyLinked && !curr.marked {
+			ptrs = append(ptrs, atomic.LoadPointer(&curr.value))
+		}
+	}
+	return ptrs
+}
+
+//Snapshot returns every key & value of the list in ascending order,
+//as they were at a single point in time.
+//
+//To get there Snapshot blocks Set & Remove from linking, unlinking or
+//editing nodes while it copies the list, searches are not blocked.
+//On a large list this is a pause for every writer, and Set & Remove always
+//pay for a shared lock.
+func (h *Header) Snapshot() []KV {
+	spread := h.spread()
+	epoch := h.enter(spread)
+	defer h.exit(spread, epoch)
+	h.publish.Lock()
+	defer h.publish.Unlock()
+	kvs := make([]KV, 0, h.Len())
+	for curr := h.leftSentinel.nexts.get(0); curr != h.rightSentinel; curr = curr.nexts.get(0) {
+		if curr.fullyLinked && !curr.marked {
+			kvs = append(kvs, KV{Key: curr.key, Value: atomic.LoadPointer(&curr.value)})
+		}
+	}
+	return kvs
+}
+
+//Stats describes the shape of a list, see Header.Stats
+//
+//When the max level is more than 1, a node always goes up to layer 1 at
+//least and never to the top layer, which only holds the sentinels:
+//Levels[0] and Levels[len(Levels)-1] are then always 0.
+type Stats struct {
+	Len          int   // number of nodes
+	Levels       []int // Levels[i] is the number of nodes going up to layer i
+	HighestLevel int   // highest layer a node goes up to, -1 if empty
+}
+
+//Stats walks the list to describe its shape,
+//it can be used to tune WithProbability & WithMaxLevel.
+func (h *Header) Stats() Stats {
+	spread := h.spread()
+	epoch := h.enter(spread)
+	defer h.exit(spread, epoch)
+	stats := Stats{
+		Levels:       make([]int, h.maxLevel),
+		HighestLevel: -1,
+	}
+	for curr := h.leftSentinel.nexts.get(0); curr != h.rightSentinel; curr = curr.nexts.get(0) {
+		if !curr.fullyLinked || curr.marked {
+			continue
+		}
+		level := len(curr.nexts) - 1
+		stats.Len++
+		stats.Levels[level]++
+		if level > stats.HighestLevel {
+			stats.HighestLevel = level
+		}
+	}
+	return stats
+}
+
 //newNode instanciates a *node with topLayer set right
 // and a slice of `topLayer` sized nexts
 func newNode(ptr unsafe.Pointer, v, topLayer int) *node {
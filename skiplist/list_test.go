@@ -1,6 +1,9 @@
 package skiplist
 
 import (
+	"math"
+	"math/rand"
+	"sort"
 	"testing"
 
 	"sync"
@@ -147,3 +150,604 @@ func checkList(t *testing.T, sl *Header) {
 		curr.lock.Unlock()
 	}
 }
+
+func TestOptions(t *testing.T) {
+	for _, opts := range [][]Option{
+		{WithMaxLevel(1)},
+		{WithMaxLevel(2)},
+		{WithMaxLevel(64), WithProbability(.9)},
+		{WithProbability(.1)},
+	} {
+		sl := New(opts...)
+		in := 1000
+		insert(t, sl, in, false)
+		checkList(t, sl)
+		if sl.Len() != in {
+			t.Fatal("inserted ", in, " items and size is: ", sl.Len())
+		}
+		for curr := sl.leftSentinel.nexts.get(0); curr != sl.rightSentinel; curr = curr.nexts.get(0) {
+			if len(curr.nexts) > sl.maxLevel {
+				t.Fatalf("node %d has %d layers, max level is %d", curr.key, len(curr.nexts), sl.maxLevel)
+			}
+		}
+		remove(t, sl, in, false)
+		if sl.Len() != 0 {
+			t.Fatal("removed ", in, " items and size is: ", sl.Len())
+		}
+	}
+
+	sl := New()
+	if len(sl.leftSentinel.nexts) != maxlevel || sl.p != p {
+		t.Fatal("New() should default to maxlevel & p")
+	}
+}
+
+func TestOptionsBounds(t *testing.T) {
+	for name, opt := range map[string]func(){
+		"max level 0":    func() { WithMaxLevel(0) },
+		"max level 65":   func() { WithMaxLevel(65) },
+		"probability 0":  func() { WithProbability(0) },
+		"probability 1":  func() { WithProbability(1) },
+		"probability -1": func() { WithProbability(-1) },
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%s should have panicked", name)
+				}
+			}()
+			opt()
+		}()
+	}
+}
+
+func TestSeed(t *testing.T) {
+	levels := func(sl *Header) (l []int) {
+		for curr := sl.leftSentinel.nexts.get(0); curr != sl.rightSentinel; curr = curr.nexts.get(0) {
+			l = append(l, len(curr.nexts))
+		}
+		return
+	}
+	in := 1000
+	a, b := New(WithSeed(42)), New(WithSeed(42))
+	insert(t, a, in, false)
+	insert(t, b, in, false)
+	la, lb := levels(a), levels(b)
+	if len(la) != in || len(lb) != in {
+		t.Fatalf("expected %d nodes, got %d & %d", in, len(la), len(lb))
+	}
+	for i := range la {
+		if la[i] != lb[i] {
+			t.Fatalf("node %d: level %d != %d with the same seed", i, la[i], lb[i])
+		}
+	}
+
+	c := New(WithRandSource(rand.NewSource(43)))
+	insert(t, c, in, false)
+	lc := levels(c)
+	same := true
+	for i := range la {
+		same = same && la[i] == lc[i]
+	}
+	if same {
+		t.Fatal("different seeds gave the same levels")
+	}
+}
+
+func TestPopMin(t *testing.T) {
+	sl := New()
+	if _, _, ok := sl.PopMin(); ok {
+		t.Fatal("popped something from an empty list")
+	}
+	values := []int{5, -3, 42, 0, 7}
+	for i := range values {
+		sl.Set(values[i], unsafe.Pointer(&values[i]))
+	}
+	for _, expected := range []int{-3, 0, 5, 7, 42} {
+		key, ptr, ok := sl.PopMin()
+		if !ok || key != expected || *(*int)(ptr) != expected {
+			t.Fatalf("expected to pop %d, got %d (ok: %t)", expected, key, ok)
+		}
+		checkList(t, sl)
+	}
+	if _, _, ok := sl.PopMin(); ok || sl.Len() != 0 {
+		t.Fatal("popped something from an empty list")
+	}
+}
+
+func TestParallelPopMin(t *testing.T) {
+	sl := New()
+	in := 10000
+	insert(t, sl, in, false)
+
+	workers := 8
+	popped := make([][]int, workers)
+	wg := sync.WaitGroup{}
+	wg.Add(workers)
+	for w := 0; w < workers; w++ {
+		go func(w int) {
+			defer wg.Done()
+			for {
+				key, _, ok := sl.PopMin()
+				if !ok {
+					return
+				}
+				popped[w] = append(popped[w], key)
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	seen := make([]bool, in)
+	for w := range popped {
+		for i, key := range popped[w] {
+			if i > 0 && key <= popped[w][i-1] {
+				t.Fatalf("worker %d popped %d after %d", w, key, popped[w][i-1])
+			}
+			if seen[key] {
+				t.Fatalf("%d was popped twice", key)
+			}
+			seen[key] = true
+		}
+	}
+	for key := range seen {
+		if !seen[key] {
+			t.Fatalf("%d was never popped", key)
+		}
+	}
+	if sl.Len() != 0 {
+		t.Fatal("expected list to be of length 0, got ", sl.Len())
+	}
+}
+
+func TestRankSelect(t *testing.T) {
+	for _, sl := range []*Header{New(), New(WithIndex()), New(WithIndex(), WithMaxLevel(1)), New(WithIndex(), WithMaxLevel(4))} {
+		rnd := rand.New(rand.NewSource(1))
+		present := map[int]bool{}
+		for i := 0; i < 3000; i++ {
+			v := rnd.Intn(500) - 250
+			if rnd.Intn(3) == 0 {
+				sl.Remove(v)
+				delete(present, v)
+			} else {
+				sl.Set(v, unsafe.Pointer(nil))
+				present[v] = true
+			}
+		}
+		if sl.indexed {
+			checkWidths(t, sl)
+		}
+
+		sorted := make([]int, 0, len(present))
+		for v := range present {
+			sorted = append(sorted, v)
+		}
+		sort.Ints(sorted)
+		for k, v := range sorted {
+			key, _, ok := sl.Select(k)
+			if !ok || key != v {
+				t.Fatalf("Select(%d): expected %d, got %d (ok: %t)", k, v, key, ok)
+			}
+		}
+		for _, k := range []int{-1, len(sorted), len(sorted) + 1} {
+			if _, _, ok := sl.Select(k); ok {
+				t.Fatalf("Select(%d) should be out of bounds", k)
+			}
+		}
+		for v := -260; v <= 260; v++ {
+			expected := sort.SearchInts(sorted, v)
+			if rank := sl.Rank(v); rank != expected {
+				t.Fatalf("Rank(%d): expected %d, got %d", v, expected, rank)
+			}
+		}
+		below, above := math.MinInt32, math.MaxInt32 // past the sentinels where int is 64 bits
+		below--
+		above++
+		for _, v := range []int{math.MinInt32, below, math.MaxInt32, above} {
+			expected := sort.SearchInts(sorted, v)
+			if rank := sl.Rank(v); rank != expected {
+				t.Fatalf("Rank(%d): expected %d, got %d", v, expected, rank)
+			}
+		}
+	}
+}
+
+func TestParallelIndex(t *testing.T) {
+	sl := New(WithIndex())
+	workers := 4
+	wg := sync.WaitGroup{}
+	wg.Add(workers)
+	for w := 0; w < workers; w++ {
+		go func(w int) {
+			defer wg.Done()
+			rnd := rand.New(rand.NewSource(int64(w)))
+			for i := 0; i < 2000; i++ {
+				v := rnd.Intn(200)
+				if rnd.Intn(2) == 0 {
+					sl.Remove(v)
+				} else {
+					sl.Set(v, unsafe.Pointer(nil))
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+	checkWidths(t, sl)
+}
+
+//checkWidths verifies that every width matches the distance at layer 0
+func checkWidths(t *testing.T, sl *Header) {
+	pos := map[*node]int{}
+	i := 0
+	for curr := sl.leftSentinel; curr != nil; curr = curr.nexts.get(0) {
+		pos[curr] = i
+		i++
+	}
+	for curr := sl.leftSentinel; curr != sl.rightSentinel; curr = curr.nexts.get(0) {
+		for layer := range curr.nexts {
+			if expected := pos[curr.nexts.get(layer)] - pos[curr]; curr.width(layer) != expected {
+				t.Fatalf("node %d layer %d: width is %d, expected %d", curr.key, layer, curr.width(layer), expected)
+			}
+		}
+	}
+}
+
+func TestSnapshot(t *testing.T) {
+	sl := New()
+	if kvs := sl.Snapshot(); len(kvs) != 0 {
+		t.Fatal("snapshot of an empty list has ", len(kvs), " items")
+	}
+	values := []int{5, -3, 42, 0, 7}
+	for i := range values {
+		sl.Set(values[i], unsafe.Pointer(&values[i]))
+	}
+	kvs := sl.Snapshot()
+	for i, expected := range []int{-3, 0, 5, 7, 42} {
+		if kvs[i].Key != expected || *(*int)(kvs[i].Value) != expected {
+			t.Fatalf("snapshot[%d]: expected %d, got %d", i, expected, kvs[i].Key)
+		}
+	}
+}
+
+func TestParallelSnapshot(t *testing.T) {
+	sl := New()
+	done := make(chan bool)
+	wg := sync.WaitGroup{}
+	wg.Add(1)
+	//move a token to the left, it is always in the list at least once
+	sl.Set(10000, unsafe.Pointer(nil))
+	go func() {
+		defer wg.Done()
+		for k := 10000; k > 0; k-- {
+			sl.Set(k-1, unsafe.Pointer(nil))
+			sl.Remove(k)
+		}
+		close(done)
+	}()
+	for running := true; running; {
+		select {
+		case <-done:
+			running = false
+		default:
+		}
+		kvs := sl.Snapshot()
+		if len(kvs) != 1 && len(kvs) != 2 {
+			t.Fatal("expected 1 or 2 items in snapshot, got ", len(kvs))
+		}
+		if len(kvs) == 2 && kvs[0].Key+1 != kvs[1].Key {
+			t.Fatalf("inconsistent snapshot: %v", kvs)
+		}
+	}
+	wg.Wait()
+}
+
+func TestSetBatch(t *testing.T) {
+	for _, sl := range []*Header{New(), New(WithIndex())} {
+		values := make([]int, 1000)
+		kvs := make([]KV, 0, len(values))
+		for i := range values {
+			values[i] = i * 2
+			kvs = append(kvs, KV{Key: values[i], Value: unsafe.Pointer(&values[i])})
+		}
+		if added := sl.SetBatch(kvs); added != len(kvs) {
+			t.Fatalf("expected %d keys to be added, got %d", len(kvs), added)
+		}
+		//out of order, with edits & duplicates
+		more := []KV{{Key: 3}, {Key: 1}, {Key: 2}, {Key: 5}, {Key: 5}, {Key: 4001}, {Key: -1}}
+		if added := sl.SetBatch(more); added != 5 {
+			t.Fatalf("expected 5 keys to be added, got %d", added)
+		}
+		checkList(t, sl)
+		if sl.indexed {
+			checkWidths(t, sl)
+		}
+		if sl.Len() != len(kvs)+5 {
+			t.Fatalf("expected list to be of length %d, got %d", len(kvs)+5, sl.Len())
+		}
+		for i := range values {
+			ptr, found := sl.Get(values[i])
+			if values[i] == 2 {
+				if !found || ptr != nil {
+					t.Fatal("2 should have been edited")
+				}
+				continue
+			}
+			if !found || *(*int)(ptr) != values[i] {
+				t.Fatalf("could not get %d back", values[i])
+			}
+		}
+		prev := sl.leftSentinel
+		for curr := prev.nexts.get(0); curr != sl.rightSentinel; prev, curr = curr, curr.nexts.get(0) {
+			if curr.key <= prev.key {
+				t.Fatalf("list is not sorted, %d after %d", curr.key, prev.key)
+			}
+		}
+	}
+}
+
+func BenchmarkSetBatch(b *testing.B) {
+	kvs := sortedKVs(10000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		New().SetBatch(kvs)
+	}
+}
+
+func BenchmarkSetLoop(b *testing.B) {
+	kvs := sortedKVs(10000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		sl := New()
+		for _, kv := range kvs {
+			sl.Set(kv.Key, kv.Value)
+		}
+	}
+}
+
+func sortedKVs(n int) []KV {
+	kvs := make([]KV, n)
+	for i := range kvs {
+		kvs[i].Key = i
+	}
+	return kvs
+}
+
+func TestStats(t *testing.T) {
+	stats := New(WithMaxLevel(8)).Stats()
+	if stats.Len != 0 || len(stats.Levels) != 8 || stats.HighestLevel != -1 {
+		t.Fatalf("unexpected stats for an empty list: %+v", stats)
+	}
+
+	sl := New(WithSeed(1))
+	in := 4000
+	insert(t, sl, in, false)
+	stats = sl.Stats()
+	if stats.Len != in || len(stats.Levels) != maxlevel {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+	total := 0
+	for level, count := range stats.Levels {
+		total += count
+		if count != 0 && level > stats.HighestLevel {
+			t.Fatalf("level %d has %d nodes, highest level is %d", level, count, stats.HighestLevel)
+		}
+	}
+	if total != in || stats.Levels[stats.HighestLevel] == 0 {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+	//nodes go up to layer 1 at least, see Stats, then
+	//each layer has half the nodes of the one below
+	if stats.Levels[0] != 0 || stats.Levels[maxlevel-1] != 0 {
+		t.Fatalf("unexpected nodes on layer 0 or the top layer: %+v", stats)
+	}
+	expected := float64(in) * (1 - p)
+	for level := 1; level <= 4; level++ {
+		if got := float64(stats.Levels[level]); got < expected*.75 || got > expected*1.25 {
+			t.Fatalf("level %d has %.0f nodes, expected about %.0f", level, got, expected)
+		}
+		expected *= p
+	}
+}
+
+func TestDuplicates(t *testing.T) {
+	for _, sl := range []*Header{New(WithDuplicates()), New(WithDuplicates(), WithIndex())} {
+		values := []int{0, 1, 2}
+		for i := range values {
+			if !sl.Set(1, unsafe.Pointer(&values[i])) {
+				t.Fatal("Set should always add WithDuplicates")
+			}
+		}
+		sl.Set(0, unsafe.Pointer(nil))
+		sl.Set(2, unsafe.Pointer(nil))
+		if sl.Len() != 5 {
+			t.Fatal("expected list to be of length 5, got ", sl.Len())
+		}
+		if sl.indexed {
+			checkWidths(t, sl)
+		}
+		if ptr, found := sl.Get(1); !found || *(*int)(ptr) != 2 {
+			t.Fatal("Get should return the last value Set")
+		}
+		ptrs := sl.GetAll(1)
+		if len(ptrs) != 3 {
+			t.Fatal("expected 3 values, got ", len(ptrs))
+		}
+		for i, ptr := range ptrs {
+			if *(*int)(ptr) != 2-i {
+				t.Fatalf("GetAll[%d] is %d, expected %d", i, *(*int)(ptr), 2-i)
+			}
+		}
+		for i := 2; i >= 0; i-- {
+			if removed := sl.remove(1); removed == nil || *(*int)(removed.value) != i {
+				t.Fatalf("expected to remove value %d", i)
+			}
+			checkList(t, sl)
+			checkLayers(t, sl)
+			if sl.indexed {
+				checkWidths(t, sl)
+			}
+			if sl.Contains(1) != (i > 0) {
+				t.Fatal("Contains(1) should be ", i > 0)
+			}
+		}
+		if sl.Remove(1) {
+			t.Fatal("removed a key that isn't there anymore")
+		}
+		if sl.Len() != 2 || !sl.Contains(0) || !sl.Contains(2) {
+			t.Fatal("removed too much")
+		}
+	}
+}
+
+func TestParallelDuplicates(t *testing.T) {
+	for _, sl := range []*Header{New(WithDuplicates()), New(WithDuplicates(), WithIndex())} {
+		workers := 8
+		times := 500
+		removed := make([]int, workers)
+		wg := sync.WaitGroup{}
+		wg.Add(workers)
+		for w := 0; w < workers; w++ {
+			go func(w int) {
+				defer wg.Done()
+				rnd := rand.New(rand.NewSource(int64(w)))
+				for i := 0; i < times; i++ {
+					sl.Set(rnd.Intn(4), unsafe.Pointer(nil))
+					if sl.Remove(rnd.Intn(4)) {
+						removed[w]++
+					}
+				}
+			}(w)
+		}
+		wg.Wait()
+		checkList(t, sl)
+		checkLayers(t, sl)
+		if sl.indexed {
+			checkWidths(t, sl)
+		}
+		total := 0
+		for _, r := range removed {
+			total += r
+		}
+		if sl.Len() != workers*times-total || sl.Stats().Len != sl.Len() {
+			t.Fatalf("inserted %d, removed %d, but length is %d", workers*times, total, sl.Len())
+		}
+		for v := 0; v < 4; v++ {
+			for sl.Remove(v) {
+			}
+		}
+		if sl.Len() != 0 {
+			t.Fatal("expected list to be of length 0, got ", sl.Len())
+		}
+	}
+}
+
+func TestParallelRemoveDuplicates(t *testing.T) {
+	sl := New(WithDuplicates())
+	in := 4000
+	for i := 0; i < in; i++ {
+		sl.Set(1, unsafe.Pointer(nil))
+	}
+	workers := 32
+	failed := make([]int, workers)
+	wg := sync.WaitGroup{}
+	wg.Add(workers)
+	for w := 0; w < workers; w++ {
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < in/workers; i++ {
+				if !sl.Remove(1) {
+					failed[w]++
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+	for w := range failed {
+		if failed[w] != 0 {
+			t.Fatalf("worker %d failed to remove %d live duplicates", w, failed[w])
+		}
+	}
+	if sl.Len() != 0 || sl.Contains(1) {
+		t.Fatal("expected list to be of length 0, got ", sl.Len())
+	}
+}
+
+func TestParallelDuplicatesLayers(t *testing.T) {
+	for _, sl := range []*Header{New(WithDuplicates()), New(WithDuplicates(), WithIndex())} {
+		workers := 8
+		wg := sync.WaitGroup{}
+		wg.Add(workers)
+		for w := 0; w < workers; w++ {
+			go func(w int) {
+				defer wg.Done()
+				for i := 0; i < 3000; i++ {
+					if w%2 == 0 {
+						sl.Set(1, unsafe.Pointer(nil))
+					} else {
+						sl.Remove(1)
+					}
+				}
+			}(w)
+		}
+		wg.Wait()
+		checkList(t, sl)
+		checkLayers(t, sl)
+		if sl.indexed {
+			checkWidths(t, sl)
+		}
+		if n := len(sl.GetAll(1)); n != sl.Len() {
+			t.Fatalf("%d duplicates can be found, but length is %d", n, sl.Len())
+		}
+	}
+}
+
+//checkLayers verifies that every layer holds, in order,
+//exactly the nodes of layer 0 that go up to it
+func checkLayers(t *testing.T, sl *Header) {
+	for layer := 1; layer < sl.maxLevel; layer++ {
+		curr := sl.leftSentinel.nexts.get(layer)
+		for n := sl.leftSentinel.nexts.get(0); n != sl.rightSentinel; n = n.nexts.get(0) {
+			if len(n.nexts) <= layer {
+				continue
+			}
+			if curr != n {
+				t.Fatalf("layer %d: node %d is missing or out of order", layer, n.key)
+			}
+			curr = curr.nexts.get(layer)
+		}
+		if curr != sl.rightSentinel {
+			t.Fatalf("layer %d: node %d is not on layer 0", layer, curr.key)
+		}
+	}
+}
+
+func TestUnlinkDuplicates(t *testing.T) {
+	for _, sl := range []*Header{New(WithDuplicates()), New(WithDuplicates(), WithIndex())} {
+		sl.Set(1, unsafe.Pointer(nil))
+		n := sl.leftSentinel.nexts.get(0)
+		sl.Set(1, unsafe.Pointer(nil))
+		m := sl.leftSentinel.nexts.get(0) // m is before n
+
+		//succs found by a search that ran while m was linked point to m
+		preds, succs := sl.newFullNodeSlice(), sl.newFullNodeSlice()
+		sl.findNode(1, preds, succs)
+		if succs.get(0) != m {
+			t.Fatal("expected the search to stop on the last duplicate Set")
+		}
+		n.marked = true
+		if sl.unlink(n, preds, succs) {
+			t.Fatal("unlinked n from preds that are right before another duplicate")
+		}
+		checkLayers(t, sl)
+		if !sl.predsOf(n, preds, succs) || !sl.unlink(n, preds, succs) {
+			t.Fatal("failed to unlink n from its own preds")
+		}
+		checkLayers(t, sl)
+		if sl.indexed {
+			checkWidths(t, sl)
+		}
+		if sl.leftSentinel.nexts.get(0) != m || m.nexts.get(0) != sl.rightSentinel {
+			t.Fatal("expected only m to be left")
+		}
+	}
+}
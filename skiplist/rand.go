@@ -6,6 +6,7 @@ import (
 	"time"
 )
 
+// default values of a list, see WithProbability & WithMaxLevel
 const (
 	p = .5 // the p level defines the probability that a node
 	// with a value at level i also has a value at i+1.  This number
@@ -40,18 +41,29 @@ func (ls *lockedSource) Seed(seed int64) {
 	ls.mu.Unlock()
 }
 
-// generator will be the common generator to create random numbers. It
-// is seeded with unix nanosecond when this line is executed at runtime,
-// and only executed once ensuring all random numbers come from the same
-// randomly seeded generator.
-var generator = rand.New(&lockedSource{src: rand.NewSource(time.Now().UnixNano())})
+// generator creates random levels for a list, every list owns one.
+type generator struct {
+	*rand.Rand
+}
 
-func flipCoin() bool {
-	return generator.Float64() >= p
+// newGenerator returns a generator safe for concurrent use, drawing from src.
+// When src is nil it is seeded with unix nanosecond.
+func newGenerator(src rand.Source) *generator {
+	if src == nil {
+		src = rand.NewSource(time.Now().UnixNano())
+	}
+	return &generator{rand.New(&lockedSource{src: src})}
 }
 
-func generateLevel(maxLevel int) (level int) {
-	for level = 1; level < maxLevel-1 && flipCoin(); level++ {
+func (g *generator) flipCoin(p float64) bool {
+	return g.Float64() < p
+}
+
+func (g *generator) generateLevel(maxLevel int, p float64) (level int) {
+	if maxLevel == 1 {
+		return 0
+	}
+	for level = 1; level < maxLevel-1 && g.flipCoin(p); level++ {
 	}
 	return level
 }
@@ -0,0 +1,83 @@
+package skiplist
+
+import (
+	"context"
+	"sync/atomic"
+	"unsafe"
+)
+
+//waiters of one key
+type waiters struct {
+	ch chan struct{} // closed when the key is added
+	n  int           // number of goroutines waiting on ch
+}
+
+//WaitFor returns the value at v as soon as v is in the list.
+//
+//It blocks until a Set adds v, or returns ctx.Err() if ctx is done first.
+func (h *Header) WaitFor(ctx context.Context, v int) (unsafe.Pointer, error) {
+	for {
+		if ptr, found := h.Get(v); found {
+			return ptr, nil
+		}
+		ch := h.wait(v)
+		//v could have been added before we started waiting
+		if ptr, found := h.Get(v); found {
+			h.unwait(v, ch)
+			return ptr, nil
+		}
+		select {
+		case <-ch:
+			//v was added, but it could already be removed
+		case <-ctx.Done():
+			h.unwait(v, ch)
+			return nil, ctx.Err()
+		}
+	}
+}
+
+//wait registers a waiter for v and returns a chan closed when v is added
+func (h *Header) wait(v int) chan struct{} {
+	h.waitLock.Lock()
+	defer h.waitLock.Unlock()
+	if h.waiters == nil {
+		h.waiters = map[int]*waiters{}
+	}
+	w := h.waiters[v]
+	if w == nil {
+		w = &waiters{ch: make(chan struct{})}
+		h.waiters[v] = w
+		atomic.AddInt32(&h.waiting, 1)
+	}
+	w.n++
+	return w.ch
+}
+
+//unwait unregisters a waiter of ch for v, if v was not added in the meantime
+func (h *Header) unwait(v int, ch chan struct{}) {
+	h.waitLock.Lock()
+	defer h.waitLock.Unlock()
+	w := h.waiters[v]
+	if w == nil || w.ch != ch {
+		return
+	}
+	w.n--
+	if w.n == 0 {
+		delete(h.waiters, v)
+		atomic.AddInt32(&h.waiting, -1)
+	}
+}
+
+//wake releases every waiter of v, called once v was added.
+func (h *Header) wake(v int) {
+	if atomic.LoadInt32(&h.waiting) == 0 {
+		return
+	}
+	h.waitLock.Lock()
+	defer h.waitLock.Unlock()
+	if w := h.waiters[v]; w != nil {
+		delete(h.waiters, v)
+		atomic.AddInt32(&h.waiting, -1)
+		close(w.ch)
+	}
+}
@@ -0,0 +1,70 @@
+package skiplist
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+	"unsafe"
+)
+
+func TestWaitFor(t *testing.T) {
+	sl := New()
+	one := 1
+	sl.Set(1, unsafe.Pointer(&one))
+	ptr, err := sl.WaitFor(context.Background(), 1)
+	if err != nil || *(*int)(ptr) != one {
+		t.Fatalf("expected to get %d right away, got %v", one, err)
+	}
+
+	two := 2
+	workers := 4
+	wg := sync.WaitGroup{}
+	wg.Add(workers)
+	for w := 0; w < workers; w++ {
+		go func() {
+			defer wg.Done()
+			ptr, err := sl.WaitFor(context.Background(), 2)
+			if err != nil || *(*int)(ptr) != two {
+				t.Errorf("expected to get %d, got %v", two, err)
+			}
+		}()
+	}
+	time.Sleep(10 * time.Millisecond)
+	sl.Set(2, unsafe.Pointer(&two))
+	wg.Wait()
+	if len(sl.waiters) != 0 || sl.waiting != 0 {
+		t.Fatal("waiters were not released")
+	}
+}
+
+func TestWaitForCancel(t *testing.T) {
+	sl := New()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := sl.WaitFor(ctx, 3); err != context.DeadlineExceeded {
+		t.Fatalf("expected %v, got %v", context.DeadlineExceeded, err)
+	}
+	if len(sl.waiters) != 0 || sl.waiting != 0 {
+		t.Fatal("waiters were not released")
+	}
+}
+
+func TestParallelWaitFor(t *testing.T) {
+	sl := New()
+	in := 1000
+	wg := sync.WaitGroup{}
+	wg.Add(in)
+	for v := 0; v < in; v++ {
+		go func(v int) {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			if _, err := sl.WaitFor(ctx, v); err != nil {
+				t.Errorf("waiting for %d: %v", v, err)
+			}
+		}(v)
+	}
+	insert(t, sl, in, false)
+	wg.Wait()
+}